@@ -1,17 +1,68 @@
 package internal_models
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/influxdata/telegraf"
 )
 
+// OverflowPolicy controls what Buffer.Add does once the buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered metric to make room for the
+	// incoming one. This was Buffer's original, hard-coded behavior and
+	// remains the default.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the incoming metric, leaving the buffer's existing
+	// contents untouched.
+	DropNewest
+	// Block waits for room to free up (via Batch draining the channel)
+	// before adding the metric.
+	Block
+	// Reject refuses to add the metric, counting it separately from drops so
+	// callers can tell "we made room by throwing something away" apart from
+	// "we refused the new data outright".
+	Reject
+)
+
+// BufferStats is a snapshot of a Buffer's accumulated overflow counters.
+type BufferStats struct {
+	Drops   int64
+	Rejects int64
+}
+
 type Buffer struct {
-	buf   chan telegraf.Metric
-	drops int64
+	buf    chan telegraf.Metric
+	policy OverflowPolicy
+
+	// evictMu serializes DropOldest's "it's full, so pop the oldest and push
+	// the new one" sequence against Batch's own draining of the same
+	// channel. Without it, Batch could drain buf between DropOldest's full
+	// check and its evict, leaving the evicting goroutine's <-b.buf to
+	// block on data that's no longer there. RunningOutput's worker pool is
+	// what makes that interleaving possible: Add and Batch are no longer
+	// guaranteed to run from a single goroutine.
+	evictMu sync.Mutex
+
+	// drops and rejects are updated with the atomic package for the same
+	// reason.
+	drops   int64
+	rejects int64
 }
 
+// NewBuffer creates a Buffer with the default DropOldest overflow policy.
 func NewBuffer(size int) *Buffer {
+	return NewBufferWithPolicy(size, DropOldest)
+}
+
+// NewBufferWithPolicy creates a Buffer that applies the given OverflowPolicy
+// once it reaches size.
+func NewBufferWithPolicy(size int, policy OverflowPolicy) *Buffer {
 	return &Buffer{
-		buf: make(chan telegraf.Metric, size),
+		buf:    make(chan telegraf.Metric, size),
+		policy: policy,
 	}
 }
 
@@ -23,19 +74,56 @@ func (b *Buffer) Len() int {
 	return len(b.buf)
 }
 
-func (b *Buffer) Add(metrics ...telegraf.Metric) {
+// Stats returns a snapshot of the buffer's accumulated drop/reject counters,
+// suitable for surfacing as an internal telegraf metric.
+func (b *Buffer) Stats() BufferStats {
+	return BufferStats{
+		Drops:   atomic.LoadInt64(&b.drops),
+		Rejects: atomic.LoadInt64(&b.rejects),
+	}
+}
+
+// Add appends metrics to the buffer, applying the buffer's OverflowPolicy
+// once it is full, and returns the number of metrics dropped and rejected
+// as a result.
+func (b *Buffer) Add(metrics ...telegraf.Metric) (drops int, rejects int) {
 	for i, _ := range metrics {
-		select {
-		case b.buf <- metrics[i]:
-		default:
-			b.drops++
-			<-b.buf
+		switch b.policy {
+		case Block:
 			b.buf <- metrics[i]
+		case DropNewest:
+			select {
+			case b.buf <- metrics[i]:
+			default:
+				atomic.AddInt64(&b.drops, 1)
+				drops++
+			}
+		case Reject:
+			select {
+			case b.buf <- metrics[i]:
+			default:
+				atomic.AddInt64(&b.rejects, 1)
+				rejects++
+			}
+		default: // DropOldest
+			b.evictMu.Lock()
+			select {
+			case b.buf <- metrics[i]:
+			default:
+				atomic.AddInt64(&b.drops, 1)
+				drops++
+				<-b.buf
+				b.buf <- metrics[i]
+			}
+			b.evictMu.Unlock()
 		}
 	}
+	return drops, rejects
 }
 
 func (b *Buffer) Batch(batchSize int) []telegraf.Metric {
+	b.evictMu.Lock()
+	defer b.evictMu.Unlock()
 	n := min(len(b.buf), batchSize)
 	out := make([]telegraf.Metric, n)
 	for i := 0; i < n; i++ {
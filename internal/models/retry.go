@@ -0,0 +1,92 @@
+package internal_models
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// CircuitState represents the state of a RunningOutput's circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed is the normal state: every batch is attempted.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen short-circuits writes without touching the output, so a
+	// known-down endpoint isn't hammered while other outputs keep flushing.
+	CircuitOpen
+	// CircuitHalfOpen lets a single batch through as a probe, to decide
+	// whether to close the circuit again or go back to open.
+	CircuitHalfOpen
+)
+
+const (
+	// DefaultRetryInitialInterval is the backoff before the first retry of a
+	// failing output.
+	DefaultRetryInitialInterval = 1 * time.Second
+	// DefaultRetryMaxInterval caps how long backoff is allowed to grow to.
+	DefaultRetryMaxInterval = 1 * time.Minute
+	// DefaultCircuitBreakerThreshold is how many consecutive write failures
+	// are tolerated before the circuit opens.
+	DefaultCircuitBreakerThreshold = 3
+)
+
+// RetryPolicy configures the exponential backoff RunningOutput applies
+// between write attempts to a failing output, and the circuit breaker that
+// stops attempts altogether once an output looks persistently down.
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Threshold is the number of consecutive failures that open the circuit.
+	Threshold int
+}
+
+// NewRetryPolicy creates a RetryPolicy, filling in defaults for any zero
+// value.
+func NewRetryPolicy(initial, max time.Duration, threshold int) *RetryPolicy {
+	if initial == 0 {
+		initial = DefaultRetryInitialInterval
+	}
+	if max == 0 {
+		max = DefaultRetryMaxInterval
+	}
+	if threshold == 0 {
+		threshold = DefaultCircuitBreakerThreshold
+	}
+	return &RetryPolicy{
+		InitialInterval: initial,
+		MaxInterval:     max,
+		Threshold:       threshold,
+	}
+}
+
+// nextBackoff returns how long to wait before probing again after the nth
+// consecutive failure since the circuit opened (n starting at 1):
+// InitialInterval doubled once per failure after the first, up to
+// MaxInterval, with up to 50% jitter so outputs that failed at the same
+// time don't all retry in lockstep.
+func (p *RetryPolicy) nextBackoff(n int) time.Duration {
+	d := p.InitialInterval
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= p.MaxInterval {
+			d = p.MaxInterval
+			break
+		}
+	}
+	jitter := time.Duration(rand.Int63n(int64(d))) / 2
+	return d/2 + jitter
+}
+
+// circuitBreaker tracks the open/closed/half-open state RunningOutput uses
+// to decide whether a write should be attempted at all.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	state            CircuitState
+	consecutiveFails int
+	nextRetry        time.Time
+
+	lastErr     error
+	lastErrTime time.Time
+}
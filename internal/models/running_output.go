@@ -2,6 +2,7 @@ package internal_models
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -31,6 +32,22 @@ type RunningOutput struct {
 
 	metrics     *Buffer
 	failMetrics *Buffer
+	spool       *Spool
+	retry       *RetryPolicy
+	breaker     circuitBreaker
+
+	jobCh   chan *flushJob
+	orderCh chan *flushJob
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// flushJob carries one batch through the worker pool and back to the
+// collector that buffers it on failure, so a batch submitted first falls
+// back into failMetrics first regardless of which worker finishes it.
+type flushJob struct {
+	batch  []telegraf.Metric
+	result chan error
 }
 
 func NewRunningOutput(
@@ -49,11 +66,25 @@ func NewRunningOutput(
 	ro := &RunningOutput{
 		Name:              name,
 		metrics:           NewBuffer(batchSize),
-		failMetrics:       NewBuffer(bufferLimit),
+		failMetrics:       NewBufferWithPolicy(bufferLimit, conf.OverflowPolicy),
 		Output:            output,
 		Config:            conf,
 		MetricBufferLimit: bufferLimit,
 		MetricBatchSize:   batchSize,
+		retry: NewRetryPolicy(
+			conf.RetryInitialInterval,
+			conf.RetryMaxInterval,
+			conf.CircuitBreakerThreshold,
+		),
+	}
+	if conf.SpoolDir != "" {
+		ro.spool = NewSpool(conf.SpoolDir, conf.MaxSpoolBytes, conf.SpoolSyncMode)
+		if err := ro.spool.Open(); err != nil {
+			log.Printf("E! Could not open spool dir %s for output %s: %s",
+				conf.SpoolDir, name, err)
+		} else if err := ro.spool.Drain(ro.MetricBatchSize, ro.writeWithBreaker); err != nil {
+			log.Printf("E! Could not replay spool for output %s: %s", name, err)
+		}
 	}
 	return ro
 }
@@ -83,15 +114,131 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 	ro.metrics.Add(metric)
 	if ro.metrics.Len() == ro.MetricBatchSize {
 		batch := ro.metrics.Batch(ro.MetricBatchSize)
-		err := ro.write(batch)
-		if err != nil {
-			ro.failMetrics.Add(batch...)
+		ro.flush(batch)
+	}
+}
+
+// Start spawns the FlushWorkers worker goroutines that let AddMetric and
+// Write hand batches off instead of writing them inline, so a slow output
+// doesn't block the caller while other batches are in flight, plus a single
+// collector goroutine that applies their results to failMetrics in the
+// order batches were submitted, regardless of which worker finishes first.
+// Start is a no-op when FlushWorkers is 0 or 1, leaving the original serial
+// behavior.
+func (ro *RunningOutput) Start() {
+	if ro.Config.FlushWorkers <= 1 {
+		return
+	}
+	ro.jobCh = make(chan *flushJob, ro.Config.FlushWorkers)
+	ro.orderCh = make(chan *flushJob, ro.Config.FlushWorkers)
+	ro.stopCh = make(chan struct{})
+	for i := 0; i < ro.Config.FlushWorkers; i++ {
+		ro.wg.Add(1)
+		go ro.worker()
+	}
+	ro.wg.Add(1)
+	go ro.collector()
+}
+
+// Stop signals the worker pool and collector started by Start to finish any
+// in-flight batches and exit, and waits for them to do so. Stop is a no-op
+// if Start was never called or FlushWorkers was 0 or 1.
+func (ro *RunningOutput) Stop() {
+	if ro.stopCh == nil {
+		return
+	}
+	close(ro.stopCh)
+	ro.wg.Wait()
+}
+
+func (ro *RunningOutput) worker() {
+	defer ro.wg.Done()
+	for {
+		select {
+		case job, ok := <-ro.jobCh:
+			if !ok {
+				return
+			}
+			job.result <- ro.writeWithBreaker(job.batch)
+		case <-ro.stopCh:
+			return
+		}
+	}
+}
+
+// collector applies each job's write result to failMetrics strictly in
+// submission order: it blocks on job.result before moving on to the next
+// job, even though the jobs themselves are written by workers in whatever
+// order they complete. This is what keeps the pool's concurrency from
+// turning into out-of-order fallback.
+func (ro *RunningOutput) collector() {
+	defer ro.wg.Done()
+	for {
+		select {
+		case job, ok := <-ro.orderCh:
+			if !ok {
+				return
+			}
+			select {
+			case err := <-job.result:
+				if err != nil {
+					ro.bufferFailedBatch(job.batch)
+				}
+			case <-ro.stopCh:
+				return
+			}
+		case <-ro.stopCh:
+			return
 		}
 	}
 }
 
+// flush hands a batch to the worker pool started by Start, or writes it
+// inline if no pool is running. Failures, from either path, fall back into
+// failMetrics (or the spool) for a later retry, in the order batches were
+// submitted to flush: the pool routes each batch through a flushJob that's
+// handed to a worker and, at the same time, queued on orderCh for the
+// collector, so a batch submitted first is always buffered on failure
+// before one submitted after it, even if the later batch's worker finishes
+// writing first. Once a batch is handed to the pool its outcome is handled
+// asynchronously by the collector, so flush returns nil for that batch
+// rather than blocking on it; only the inline path reports its write error
+// back to the caller.
+func (ro *RunningOutput) flush(batch []telegraf.Metric) error {
+	if ro.jobCh != nil {
+		select {
+		case <-ro.stopCh:
+			// The pool has been stopped; write the batch inline below.
+		default:
+			job := &flushJob{batch: batch, result: make(chan error, 1)}
+			select {
+			case ro.orderCh <- job:
+				select {
+				case ro.jobCh <- job:
+					return nil
+				case <-ro.stopCh:
+					return nil
+				}
+			case <-ro.stopCh:
+				return nil
+			}
+		}
+	}
+	err := ro.writeWithBreaker(batch)
+	if err != nil {
+		ro.bufferFailedBatch(batch)
+	}
+	return err
+}
+
 // Write writes all cached points to this output.
 func (ro *RunningOutput) Write() error {
+	if ro.spool != nil {
+		if err := ro.spool.Drain(ro.MetricBatchSize, ro.writeWithBreaker); err != nil {
+			log.Printf("E! Could not drain spool for output %s: %s", ro.Name, err)
+		}
+	}
+
 	if !ro.failMetrics.IsEmpty() {
 		bufLen := ro.failMetrics.Len()
 		// how many batches of failed writes we need to write.
@@ -105,22 +252,108 @@ func (ro *RunningOutput) Write() error {
 				batchSize = bufLen % ro.MetricBatchSize
 			}
 			batch := ro.failMetrics.Batch(batchSize)
-			err := ro.write(batch)
-			if err != nil {
-				ro.failMetrics.Add(batch...)
-			}
+			ro.flush(batch)
 		}
 	}
 
 	batch := ro.metrics.Batch(ro.MetricBatchSize)
+	return ro.flush(batch)
+}
+
+// writeWithBreaker wraps write with the output's circuit breaker: while the
+// circuit is open it returns the last write error without touching the
+// output at all, until the backoff for the current failure streak elapses
+// and a single probe batch is let through. Only one goroutine is ever
+// allowed to hold the half-open probe at a time; with chunk0-4's worker
+// pool calling this concurrently, every other goroutine that arrives while
+// a probe is outstanding is turned away the same way an open circuit would,
+// instead of piling onto a still-down output.
+func (ro *RunningOutput) writeWithBreaker(batch []telegraf.Metric) error {
+	ro.breaker.mu.Lock()
+	switch ro.breaker.state {
+	case CircuitOpen:
+		if time.Now().Before(ro.breaker.nextRetry) {
+			err := ro.breaker.lastErr
+			ro.breaker.mu.Unlock()
+			return err
+		}
+		// First goroutine past the backoff window wins the probe; it flips
+		// the state here, under the lock, so no other goroutine can also
+		// see CircuitOpen-with-elapsed-backoff and start a second probe.
+		ro.breaker.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		// Someone else is already probing; fail fast rather than sending a
+		// second concurrent request at an output that hasn't proven it's
+		// back yet.
+		err := ro.breaker.lastErr
+		ro.breaker.mu.Unlock()
+		return err
+	}
+	probing := ro.breaker.state == CircuitHalfOpen
+	ro.breaker.mu.Unlock()
+
 	err := ro.write(batch)
+
+	ro.breaker.mu.Lock()
+	defer ro.breaker.mu.Unlock()
 	if err != nil {
-		ro.failMetrics.Add(batch...)
+		ro.breaker.lastErr = err
+		ro.breaker.lastErrTime = time.Now()
+		ro.breaker.consecutiveFails++
+		if probing || ro.breaker.consecutiveFails >= ro.retry.Threshold {
+			ro.breaker.state = CircuitOpen
+			ro.breaker.nextRetry = time.Now().Add(ro.retry.nextBackoff(ro.breaker.consecutiveFails - ro.retry.Threshold + 1))
+		}
 		return err
 	}
+
+	ro.breaker.consecutiveFails = 0
+	ro.breaker.state = CircuitClosed
 	return nil
 }
 
+// CircuitState returns the current state of this output's circuit breaker.
+func (ro *RunningOutput) CircuitState() CircuitState {
+	ro.breaker.mu.Lock()
+	defer ro.breaker.mu.Unlock()
+	return ro.breaker.state
+}
+
+// LastWriteError returns the most recent write error and when it occurred,
+// so it can be scraped as an internal telegraf metric.
+func (ro *RunningOutput) LastWriteError() (error, time.Time) {
+	ro.breaker.mu.Lock()
+	defer ro.breaker.mu.Unlock()
+	return ro.breaker.lastErr, ro.breaker.lastErrTime
+}
+
+// bufferFailedBatch stores a batch that could not be written to the output.
+// It prefers the in-memory failMetrics buffer, but once that buffer is full
+// it spools the batch to disk instead of silently dropping it, provided a
+// spool directory is configured.
+func (ro *RunningOutput) bufferFailedBatch(batch []telegraf.Metric) {
+	if ro.spool != nil && ro.failMetrics.Len()+len(batch) > ro.MetricBufferLimit {
+		if err := ro.spool.Write(batch); err == nil {
+			return
+		} else {
+			log.Printf("E! Could not spool %d metrics for output %s: %s",
+				len(batch), ro.Name, err)
+		}
+	}
+	drops, rejects := ro.failMetrics.Add(batch...)
+	if drops > 0 || rejects > 0 {
+		log.Printf("E! Output %s: failMetrics buffer full, dropped %d and rejected %d metrics\n",
+			ro.Name, drops, rejects)
+	}
+}
+
+// FailMetricsStats returns the accumulated drop/reject counters of the
+// in-memory failMetrics buffer, so they can be surfaced as internal
+// telegraf metrics.
+func (ro *RunningOutput) FailMetricsStats() BufferStats {
+	return ro.failMetrics.Stats()
+}
+
 func (ro *RunningOutput) write(metrics []telegraf.Metric) error {
 	if len(metrics) == 0 {
 		return nil
@@ -141,4 +374,40 @@ func (ro *RunningOutput) write(metrics []telegraf.Metric) error {
 type OutputConfig struct {
 	Name   string
 	Filter Filter
+
+	// SpoolDir, if set, enables a persistent on-disk spool that RunningOutput
+	// falls back to once failMetrics is full, so that batches which could
+	// not be written survive a Telegraf restart or crash instead of being
+	// dropped. Any metrics left over from a previous run are written to the
+	// output, oldest first, when the RunningOutput is created; whatever still
+	// fails to write stays in the spool for the next Write.
+	SpoolDir string
+	// MaxSpoolBytes caps the size of a single spool segment file before a
+	// new one is rolled. Zero uses DefaultMaxSpoolBytes.
+	MaxSpoolBytes int64
+	// SpoolSyncMode controls how aggressively the spool fsyncs appends to
+	// disk. Defaults to SpoolSyncNone.
+	SpoolSyncMode SpoolSyncMode
+
+	// OverflowPolicy controls what happens to the failMetrics buffer once it
+	// fills up: whether to drop the oldest or newest metric, block until
+	// room frees up, or reject the new metric outright. Defaults to
+	// DropOldest.
+	OverflowPolicy OverflowPolicy
+
+	// RetryInitialInterval is the backoff before the first retry of a
+	// failing output. Defaults to DefaultRetryInitialInterval.
+	RetryInitialInterval time.Duration
+	// RetryMaxInterval caps how long backoff is allowed to grow to. Defaults
+	// to DefaultRetryMaxInterval.
+	RetryMaxInterval time.Duration
+	// CircuitBreakerThreshold is the number of consecutive write failures
+	// tolerated before the circuit opens and writes are short-circuited.
+	// Defaults to DefaultCircuitBreakerThreshold.
+	CircuitBreakerThreshold int
+
+	// FlushWorkers is the number of worker goroutines RunningOutput.Start
+	// spawns to write batches concurrently. 0 or 1 keeps the original serial
+	// behavior, writing batches inline as they're handed to AddMetric/Write.
+	FlushWorkers int
 }
@@ -0,0 +1,92 @@
+package internal_models
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// fakeOutput is a telegraf.Output whose Write behavior is supplied by the
+// test, so writeWithBreaker can be exercised without a real network sink.
+type fakeOutput struct {
+	writeFn func([]telegraf.Metric) error
+}
+
+func (f *fakeOutput) Connect() error                        { return nil }
+func (f *fakeOutput) Close() error                          { return nil }
+func (f *fakeOutput) Description() string                   { return "fake" }
+func (f *fakeOutput) SampleConfig() string                  { return "" }
+func (f *fakeOutput) Write(metrics []telegraf.Metric) error { return f.writeFn(metrics) }
+
+func newRunningOutputTestMetric() telegraf.Metric {
+	m, err := telegraf.NewMetric(
+		"test",
+		map[string]string{},
+		map[string]interface{}{"value": 1},
+		time.Now(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// TestCircuitBreakerHalfOpenProbeIsExclusive reproduces the scenario
+// chunk0-4's worker pool makes possible: many goroutines calling
+// writeWithBreaker concurrently once the circuit's backoff has elapsed.
+// Only one of them should be allowed to probe the still-down output at a
+// time; the rest must fail fast instead of piling onto it.
+func TestCircuitBreakerHalfOpenProbeIsExclusive(t *testing.T) {
+	var inFlight int32
+	var maxInFlight int32
+
+	out := &fakeOutput{}
+	out.writeFn = func(metrics []telegraf.Metric) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			cur := atomic.LoadInt32(&maxInFlight)
+			if n <= cur {
+				break
+			}
+			if atomic.CompareAndSwapInt32(&maxInFlight, cur, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return fmt.Errorf("output still down")
+	}
+
+	conf := &OutputConfig{
+		CircuitBreakerThreshold: 1,
+		RetryInitialInterval:    time.Millisecond,
+		RetryMaxInterval:        time.Millisecond,
+	}
+	ro := NewRunningOutput("fake", out, conf, 10, 100)
+
+	// Trip the breaker open, then force the backoff window to have already
+	// elapsed so every goroutine below races to probe.
+	ro.writeWithBreaker([]telegraf.Metric{newRunningOutputTestMetric()})
+	ro.breaker.mu.Lock()
+	ro.breaker.state = CircuitOpen
+	ro.breaker.nextRetry = time.Now()
+	ro.breaker.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ro.writeWithBreaker([]telegraf.Metric{newRunningOutputTestMetric()})
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxInFlight); got > 1 {
+		t.Fatalf("expected at most one concurrent probe write, got %d", got)
+	}
+}
@@ -0,0 +1,247 @@
+package internal_models
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// SpoolSyncMode controls how aggressively the spool flushes appends to disk.
+type SpoolSyncMode string
+
+const (
+	// SpoolSyncNone relies on the OS page cache and never calls fsync. This
+	// is the default: fast, but a kernel panic or power loss can still lose
+	// the last few appends.
+	SpoolSyncNone SpoolSyncMode = "none"
+	// SpoolSyncAlways calls fsync after every append, trading throughput for
+	// durability across anything short of disk failure.
+	SpoolSyncAlways SpoolSyncMode = "always"
+)
+
+// DefaultMaxSpoolBytes is used when OutputConfig.MaxSpoolBytes is left at its
+// zero value.
+const DefaultMaxSpoolBytes = 10 * 1024 * 1024
+
+const spoolFilePrefix = "spool-"
+const spoolFileSuffix = ".gob"
+
+// spoolRecord is the on-disk representation of a single metric. telegraf.Metric
+// is an interface, so its concrete value can't be gob-encoded directly.
+type spoolRecord struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{}
+	Time   time.Time
+}
+
+// Spool is a segmented, on-disk write-ahead log that RunningOutput falls
+// back to when it cannot hold a failed batch in memory, so that metrics
+// survive a Telegraf restart or crash instead of being silently dropped.
+// Segments are named by creation time so Drain can process them in order.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	sync     SpoolSyncMode
+
+	mu       sync.Mutex
+	curFile  *os.File
+	curCount *countingWriter
+	curWrite *gob.Encoder
+	curBytes int64
+}
+
+// countingWriter wraps a writer and tracks the number of bytes that have
+// passed through it, so Spool can track real segment size instead of an
+// approximation such as record count.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewSpool creates a Spool rooted at dir. maxBytes bounds the size of a
+// single segment file before a new one is rolled; zero uses
+// DefaultMaxSpoolBytes.
+func NewSpool(dir string, maxBytes int64, sync SpoolSyncMode) *Spool {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxSpoolBytes
+	}
+	return &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		sync:     sync,
+	}
+}
+
+// Open ensures the spool directory exists.
+func (s *Spool) Open() error {
+	return os.MkdirAll(s.dir, 0755)
+}
+
+// Write appends metrics to the current spool segment as a sequence of gob
+// records, rolling to a new segment once the current one has reached
+// maxBytes, so a segment can overrun maxBytes by up to one record.
+func (s *Spool) Write(metrics []telegraf.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range metrics {
+		if s.curFile == nil || s.curBytes >= s.maxBytes {
+			if err := s.rollLocked(); err != nil {
+				return err
+			}
+		}
+		rec := spoolRecord{
+			Name:   m.Name(),
+			Tags:   m.Tags(),
+			Fields: m.Fields(),
+			Time:   m.Time(),
+		}
+		if err := s.curWrite.Encode(&rec); err != nil {
+			return fmt.Errorf("spool: could not encode metric: %s", err)
+		}
+		s.curBytes = s.curCount.n
+		if s.sync == SpoolSyncAlways {
+			if err := s.curFile.Sync(); err != nil {
+				return fmt.Errorf("spool: could not sync segment: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// rollLocked closes the current segment, if any, and opens a new one. The
+// caller must hold s.mu.
+func (s *Spool) rollLocked() error {
+	if s.curFile != nil {
+		s.curFile.Close()
+	}
+	name := fmt.Sprintf("%s%d%s", spoolFilePrefix, time.Now().UnixNano(), spoolFileSuffix)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("spool: could not create segment %s: %s", name, err)
+	}
+	s.curFile = f
+	s.curCount = &countingWriter{w: f}
+	s.curWrite = gob.NewEncoder(s.curCount)
+	s.curBytes = 0
+	return nil
+}
+
+// Drain reads the spool oldest-first in chunks of at most batchSize
+// metrics, handing each chunk directly to writeFn instead of bulk-loading
+// the whole log into an in-memory Buffer. It is used both at startup, to
+// recover whatever a previous run left behind, and on every regular flush
+// cycle, so it must never risk stalling or overrunning a bounded Buffer the
+// way handing records to Buffer.Add one at a time would under the Block or
+// Reject OverflowPolicy.
+//
+// As soon as writeFn reports a failure, whatever hasn't been handed off yet
+// (the failed chunk plus anything still unread in that segment) is written
+// back to the spool as a new segment, which is then renamed over the source
+// segment. The rename is atomic, so a crash can't drop metrics that were
+// read but never confirmed written, and the requeued metrics keep the source
+// segment's name and so its place ahead of any later segment. Drain then
+// stops, so a still-down output doesn't duplicate what already made it out.
+func (s *Spool) Drain(batchSize int, writeFn func([]telegraf.Metric) error) error {
+	s.mu.Lock()
+	if s.curFile != nil {
+		s.curFile.Close()
+		s.curFile = nil
+		s.curWrite = nil
+	}
+	s.mu.Unlock()
+
+	names, err := s.segmentNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		metrics, err := s.readSegment(path)
+		if err != nil {
+			return fmt.Errorf("spool: could not read segment %s: %s", name, err)
+		}
+
+		for len(metrics) > 0 {
+			n := batchSize
+			if n > len(metrics) {
+				n = len(metrics)
+			}
+			if err := writeFn(metrics[:n]); err != nil {
+				if err := s.Write(metrics); err != nil {
+					return err
+				}
+				s.mu.Lock()
+				requeued := s.curFile.Name()
+				s.curFile.Close()
+				s.curFile = nil
+				s.curWrite = nil
+				s.mu.Unlock()
+				return os.Rename(requeued, path)
+			}
+			metrics = metrics[n:]
+		}
+		os.Remove(path)
+	}
+	return nil
+}
+
+// segmentNames lists the spool's segment files, oldest first.
+func (s *Spool) segmentNames() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, fi := range infos {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != spoolFileSuffix {
+			continue
+		}
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readSegment decodes every record in a segment file into metrics. Segments
+// are bounded by maxBytes, so holding one fully in memory is expected.
+func (s *Spool) readSegment(path string) ([]telegraf.Metric, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var metrics []telegraf.Metric
+	for {
+		var rec spoolRecord
+		if err := dec.Decode(&rec); err != nil {
+			break
+		}
+		m, err := telegraf.NewMetric(rec.Name, rec.Tags, rec.Fields, rec.Time)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
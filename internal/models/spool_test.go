@@ -0,0 +1,135 @@
+package internal_models
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+func newSpoolTestMetric(name string) telegraf.Metric {
+	m, err := telegraf.NewMetric(
+		name,
+		map[string]string{"tag": "t"},
+		map[string]interface{}{"value": 1},
+		time.Now(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func newTestSpool(t *testing.T, maxBytes int64) (*Spool, func()) {
+	dir, err := ioutil.TempDir("", "telegraf-spool-test")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %s", err)
+	}
+	s := NewSpool(dir, maxBytes, SpoolSyncNone)
+	if err := s.Open(); err != nil {
+		t.Fatalf("could not open spool: %s", err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestSpoolRollsSegmentAtMaxBytes(t *testing.T) {
+	s, cleanup := newTestSpool(t, 1)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		if err := s.Write([]telegraf.Metric{newSpoolTestMetric(fmt.Sprintf("m%d", i))}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+
+	names, err := s.segmentNames()
+	if err != nil {
+		t.Fatalf("segmentNames: %s", err)
+	}
+	if len(names) != 5 {
+		t.Fatalf("expected a 1 byte MaxSpoolBytes to roll a new segment per write, got %d segments", len(names))
+	}
+}
+
+func TestSpoolDrainDeliversAllMetricsInOrder(t *testing.T) {
+	s, cleanup := newTestSpool(t, DefaultMaxSpoolBytes)
+	defer cleanup()
+
+	var want []string
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("m%d", i)
+		want = append(want, name)
+		if err := s.Write([]telegraf.Metric{newSpoolTestMetric(name)}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+
+	var got []string
+	err := s.Drain(3, func(batch []telegraf.Metric) error {
+		for _, m := range batch {
+			got = append(got, m.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("drain: %s", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d metrics, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("metric %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	names, err := s.segmentNames()
+	if err != nil {
+		t.Fatalf("segmentNames: %s", err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("expected spool to be empty after a fully successful drain, got %d segments left", len(names))
+	}
+}
+
+func TestSpoolDrainRequeuesRemainderOnFailure(t *testing.T) {
+	s, cleanup := newTestSpool(t, DefaultMaxSpoolBytes)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		if err := s.Write([]telegraf.Metric{newSpoolTestMetric(fmt.Sprintf("m%d", i))}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+
+	err := s.Drain(2, func(batch []telegraf.Metric) error {
+		return fmt.Errorf("output still down")
+	})
+	if err != nil {
+		t.Fatalf("drain: %s", err)
+	}
+
+	names, err := s.segmentNames()
+	if err != nil {
+		t.Fatalf("segmentNames: %s", err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("expected the undelivered metrics to be requeued into one segment, got %d", len(names))
+	}
+
+	var recovered int
+	err = s.Drain(2, func(batch []telegraf.Metric) error {
+		recovered += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("drain: %s", err)
+	}
+	if recovered != 5 {
+		t.Fatalf("expected all 5 metrics to survive the failed drain, got %d", recovered)
+	}
+}